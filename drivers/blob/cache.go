@@ -0,0 +1,79 @@
+package blob
+
+import (
+	"container/list"
+	"sync"
+
+	"github.com/birkland/ocfl/metadata"
+)
+
+// inventoryCache is a small ETag-validated LRU of parsed object inventories, so a
+// repeat Walk over the same objects doesn't re-fetch and re-parse inventory.json
+// every time; an entry is only reused while the store still reports the ETag it
+// was cached under.
+type inventoryCache struct {
+	mu       sync.Mutex
+	capacity int
+	order    *list.List
+	entries  map[string]*list.Element
+}
+
+type cacheEntry struct {
+	key  string
+	etag string
+	inv  *metadata.Inventory
+}
+
+func newInventoryCache(capacity int) *inventoryCache {
+	return &inventoryCache{
+		capacity: capacity,
+		order:    list.New(),
+		entries:  make(map[string]*list.Element),
+	}
+}
+
+// get returns the cached inventory for key, provided its ETag still matches etag.
+func (c *inventoryCache) get(key, etag string) (*metadata.Inventory, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	el, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+
+	entry := el.Value.(*cacheEntry)
+	if entry.etag != etag {
+		c.order.Remove(el)
+		delete(c.entries, key)
+		return nil, false
+	}
+
+	c.order.MoveToFront(el)
+	return entry.inv, true
+}
+
+// put caches inv under key, tagged with etag, evicting the least recently used
+// entry if the cache is at capacity.
+func (c *inventoryCache) put(key, etag string, inv *metadata.Inventory) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if el, ok := c.entries[key]; ok {
+		entry := el.Value.(*cacheEntry)
+		entry.etag, entry.inv = etag, inv
+		c.order.MoveToFront(el)
+		return
+	}
+
+	el := c.order.PushFront(&cacheEntry{key: key, etag: etag, inv: inv})
+	c.entries[key] = el
+
+	if c.order.Len() > c.capacity {
+		oldest := c.order.Back()
+		if oldest != nil {
+			c.order.Remove(oldest)
+			delete(c.entries, oldest.Value.(*cacheEntry).key)
+		}
+	}
+}
@@ -0,0 +1,96 @@
+package blob
+
+import (
+	"testing"
+
+	"github.com/birkland/ocfl/metadata"
+)
+
+func TestInventoryCacheGetMiss(t *testing.T) {
+	c := newInventoryCache(2)
+	if _, ok := c.get("missing", "etag"); ok {
+		t.Fatalf("expected a miss for a key never put")
+	}
+}
+
+func TestInventoryCachePutThenGet(t *testing.T) {
+	c := newInventoryCache(2)
+	inv := &metadata.Inventory{ID: "obj-1"}
+	c.put("k", "etag-1", inv)
+
+	got, ok := c.get("k", "etag-1")
+	if !ok {
+		t.Fatalf("expected a hit for the key just put")
+	}
+	if got != inv {
+		t.Fatalf("expected the cached inventory to be the same instance")
+	}
+}
+
+func TestInventoryCacheStaleEtagMisses(t *testing.T) {
+	c := newInventoryCache(2)
+	c.put("k", "etag-1", &metadata.Inventory{ID: "obj-1"})
+
+	if _, ok := c.get("k", "etag-2"); ok {
+		t.Fatalf("expected a miss when the ETag no longer matches")
+	}
+	// A stale hit evicts the entry entirely, so it's not reusable even under
+	// the original ETag afterward.
+	if _, ok := c.get("k", "etag-1"); ok {
+		t.Fatalf("expected the entry to have been evicted after a stale-ETag lookup")
+	}
+}
+
+func TestInventoryCacheEvictsLeastRecentlyUsed(t *testing.T) {
+	c := newInventoryCache(2)
+	c.put("a", "etag", &metadata.Inventory{ID: "a"})
+	c.put("b", "etag", &metadata.Inventory{ID: "b"})
+	c.put("c", "etag", &metadata.Inventory{ID: "c"}) // evicts "a": least recently used
+
+	if _, ok := c.get("a", "etag"); ok {
+		t.Fatalf("expected %q to have been evicted", "a")
+	}
+	if _, ok := c.get("b", "etag"); !ok {
+		t.Fatalf("expected %q to still be cached", "b")
+	}
+	if _, ok := c.get("c", "etag"); !ok {
+		t.Fatalf("expected %q to still be cached", "c")
+	}
+}
+
+func TestInventoryCacheGetRefreshesRecency(t *testing.T) {
+	c := newInventoryCache(2)
+	c.put("a", "etag", &metadata.Inventory{ID: "a"})
+	c.put("b", "etag", &metadata.Inventory{ID: "b"})
+
+	// Touch "a" so it's no longer the least recently used.
+	if _, ok := c.get("a", "etag"); !ok {
+		t.Fatalf("expected %q to be cached", "a")
+	}
+
+	c.put("c", "etag", &metadata.Inventory{ID: "c"}) // should evict "b", not "a"
+
+	if _, ok := c.get("b", "etag"); ok {
+		t.Fatalf("expected %q to have been evicted as the new least recently used entry", "b")
+	}
+	if _, ok := c.get("a", "etag"); !ok {
+		t.Fatalf("expected %q to still be cached after being refreshed", "a")
+	}
+}
+
+func TestInventoryCachePutOverwritesExistingEntry(t *testing.T) {
+	c := newInventoryCache(2)
+	first := &metadata.Inventory{ID: "a"}
+	second := &metadata.Inventory{ID: "a-v2"}
+
+	c.put("a", "etag-1", first)
+	c.put("a", "etag-2", second)
+
+	got, ok := c.get("a", "etag-2")
+	if !ok {
+		t.Fatalf("expected a hit under the updated ETag")
+	}
+	if got != second {
+		t.Fatalf("expected the cached inventory to have been replaced")
+	}
+}
@@ -0,0 +1,318 @@
+package blob
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"path"
+	"strings"
+
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/birkland/ocfl"
+	"github.com/birkland/ocfl/metadata"
+	"github.com/birkland/ocfl/resolv"
+	"github.com/pkg/errors"
+)
+
+func init() {
+	// Registering "s3" lets resolv.Init select this driver purely from
+	// Config.Root (e.g. "s3://my-bucket/ocfl-root"), loading credentials and
+	// region from the environment the same way the AWS CLI does. Callers who
+	// need a custom aws.Config should construct a Driver with NewS3Driver
+	// directly and pass it via Config.Drivers instead.
+	resolv.RegisterScheme("s3", func(root string) (resolv.Driver, error) {
+		bucket, err := bucketFromRoot(root)
+		if err != nil {
+			return nil, err
+		}
+		cfg, err := awsconfig.LoadDefaultConfig(context.Background())
+		if err != nil {
+			return nil, errors.Wrap(err, "loading default AWS config")
+		}
+		store := NewS3Store(s3.NewFromConfig(cfg), bucket)
+		return NewS3Driver(store, bucket, 0), nil
+	})
+}
+
+func bucketFromRoot(root string) (string, error) {
+	u, err := url.Parse(root)
+	if err != nil {
+		return "", errors.Wrapf(err, "invalid root %q", root)
+	}
+	if u.Host == "" {
+		return "", fmt.Errorf("root %q has no bucket (expected s3://<bucket>/<prefix>)", root)
+	}
+	return u.Host, nil
+}
+
+const (
+	inventoryFile       = "inventory.json"
+	objectNamastePrefix = "0=ocfl_object_"
+)
+
+// Driver is a resolv.Driver backed by a Store, addressing objects via opaque URIs
+// of the form "<scheme>://<bucket>/<key prefix>", e.g. "s3://my-bucket/ocfl-root".
+// Unlike the local file driver, all path arithmetic here uses path.Join (not
+// filepath.Join): object-store keys are always "/"-delimited, regardless of the
+// OS the driver happens to run on.
+type Driver struct {
+	scheme string
+	bucket string
+	store  Store
+	cache  *inventoryCache
+}
+
+// NewDriver returns a Driver addressing objects under bucket in store, whose
+// EntityRef.Addr values are rendered as "<scheme>://<bucket>/<key>" URIs.
+// cacheSize bounds the number of parsed inventories kept in the ETag-validated
+// LRU cache; 0 selects a small built-in default.
+func NewDriver(scheme string, store Store, bucket string, cacheSize int) *Driver {
+	if cacheSize <= 0 {
+		cacheSize = 256
+	}
+	return &Driver{
+		scheme: scheme,
+		bucket: bucket,
+		store:  store,
+		cache:  newInventoryCache(cacheSize),
+	}
+}
+
+// NewS3Driver is a convenience constructor for a Driver over an S3 bucket,
+// registering it under the "s3://" URI scheme's conventions.
+func NewS3Driver(store Store, bucket string, cacheSize int) *Driver {
+	return NewDriver("s3", store, bucket, cacheSize)
+}
+
+func (d *Driver) uri(key string) string {
+	return fmt.Sprintf("%s://%s/%s", d.scheme, d.bucket, key)
+}
+
+// keyFromRoot extracts the bucket-relative key prefix from a root URI such as
+// "s3://my-bucket/ocfl-root", so Walk can use it as the starting List prefix.
+func (d *Driver) keyFromRoot(root string) string {
+	u, err := url.Parse(root)
+	if err != nil {
+		return strings.TrimPrefix(root, fmt.Sprintf("%s://%s/", d.scheme, d.bucket))
+	}
+	return strings.TrimPrefix(u.Path, "/")
+}
+
+// Walk implements resolv.Walker. It lists keys under the root prefix one
+// delimited level at a time; a level is an object root if it contains a
+// "0=ocfl_object_*" NAMASTE key, in which case its manifest is walked instead of
+// recursing further, otherwise it's treated as an intermediate node.
+//
+// loc[0] is the root address. Any further segments ({objectID}, {objectID,
+// versionID}, or {objectID, versionID, logicalPath}) scope the walk to that
+// logical coordinate and everything beneath it, per resolv.Walker's doc
+// comment: object-store keys don't generally reveal an object's logical ID up
+// front (the key prefix an object lives under need not match it, just like a
+// pairtree layout on a filesystem), so scoping doesn't skip any of the
+// underlying listing - it still visits every key under root - it just filters
+// which entities reach cb to those consistent with the requested coordinate.
+func (d *Driver) Walk(desired resolv.Select, cb func(resolv.EntityRef) error, loc ...string) error {
+	if len(loc) == 0 {
+		return fmt.Errorf("blob driver requires a root location")
+	}
+	root := loc[0]
+	scope := loc[1:]
+
+	rootRef := resolv.EntityRef{Type: ocfl.Root, Addr: root}
+	if desired.Type == ocfl.Root {
+		if len(scope) > 0 {
+			return nil // a root is never beneath a more specific logical coordinate
+		}
+		return cb(rootRef)
+	}
+
+	if len(scope) > 0 {
+		unscoped := cb
+		cb = func(r resolv.EntityRef) error {
+			if !withinScope(r, scope) {
+				return nil
+			}
+			return unscoped(r)
+		}
+	}
+
+	ctx := context.Background()
+	return d.walkPrefix(ctx, d.keyFromRoot(root), &rootRef, desired, cb)
+}
+
+// withinScope reports whether r's logical coordinates (EntityRef.Coords) are
+// consistent with scope, a {objectID, [versionID, [logicalPath]]} prefix: every
+// coordinate given in scope must equal the corresponding segment of r's own
+// coordinates, and r must be at least as deep as scope.
+func withinScope(r resolv.EntityRef, scope []string) bool {
+	coords := r.Coords()
+	if len(coords) < len(scope) {
+		return false
+	}
+	for i, want := range scope {
+		if coords[i] != want {
+			return false
+		}
+	}
+	return true
+}
+
+func (d *Driver) walkPrefix(ctx context.Context, prefix string, root *resolv.EntityRef, desired resolv.Select, cb func(resolv.EntityRef) error) error {
+	entries, err := d.store.List(ctx, prefix)
+	if err != nil {
+		return err
+	}
+
+	if objectRoot, etag := d.isObjectRoot(entries, prefix); objectRoot {
+		return d.walkObject(ctx, prefix, etag, root, desired, cb)
+	}
+
+	for _, e := range entries {
+		if !e.IsPrefix {
+			continue
+		}
+		key := strings.TrimSuffix(e.Key, "/")
+		if desired.Type >= ocfl.Intermediate {
+			err := cb(resolv.EntityRef{
+				ID:     path.Base(key),
+				Addr:   d.uri(key),
+				Type:   ocfl.Intermediate,
+				Parent: root,
+			})
+			if err != nil {
+				return err
+			}
+		}
+		if err := d.walkPrefix(ctx, e.Key, root, desired, cb); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// isObjectRoot reports whether entries (the immediate children of prefix)
+// contains an OCFL object NAMASTE declaration, and if so, the ETag of its
+// inventory.json so the inventory cache can validate a cached parse.
+func (d *Driver) isObjectRoot(entries []ObjectInfo, prefix string) (ok bool, inventoryEtag string) {
+	var found bool
+	for _, e := range entries {
+		if e.IsPrefix {
+			continue
+		}
+		name := strings.TrimPrefix(e.Key, prefix)
+		if strings.HasPrefix(name, objectNamastePrefix) {
+			found = true
+		}
+		if name == inventoryFile {
+			inventoryEtag = e.ETag
+		}
+	}
+	return found, inventoryEtag
+}
+
+func (d *Driver) walkObject(ctx context.Context, prefix, etag string, root *resolv.EntityRef, desired resolv.Select, cb func(resolv.EntityRef) error) error {
+	invKey := path.Join(prefix, inventoryFile)
+
+	inv, ok := d.cache.get(invKey, etag)
+	if !ok {
+		rc, err := d.store.Get(ctx, invKey)
+		if err != nil {
+			return err
+		}
+		defer rc.Close()
+
+		raw, err := ioutil.ReadAll(rc)
+		if err != nil {
+			return err
+		}
+
+		inv = &metadata.Inventory{}
+		if err := json.Unmarshal(raw, inv); err != nil {
+			return err
+		}
+		d.cache.put(invKey, etag, inv)
+	}
+
+	object := resolv.EntityRef{
+		ID:     inv.ID,
+		Type:   ocfl.Object,
+		Parent: root,
+		Addr:   d.uri(strings.TrimSuffix(prefix, "/")),
+	}
+
+	if desired.Type >= ocfl.Object {
+		if err := cb(object); err != nil {
+			return err
+		}
+	}
+
+	if desired.Type > ocfl.Version {
+		return nil
+	}
+
+	for vID := range inv.Versions {
+		version := resolv.EntityRef{
+			ID:     vID,
+			Type:   ocfl.Version,
+			Parent: &object,
+			Addr:   d.uri(path.Join(prefix, vID)),
+		}
+
+		if desired.Type >= ocfl.Version {
+			if err := cb(version); err != nil {
+				return err
+			}
+		}
+
+		if desired.Type > ocfl.File {
+			continue
+		}
+
+		files, _ := inv.Files(vID)
+		for _, file := range files {
+			fileRef := resolv.EntityRef{
+				ID:     file.LogicalPath,
+				Type:   ocfl.File,
+				Parent: &version,
+				Addr:   d.uri(path.Join(prefix, file.PhysicalPath)),
+			}
+			if err := cb(fileRef); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// Open implements resolv.Opener. The returned Session's Put, Delete, Move, Read
+// and Commit are not implemented for object-store backends and each returns
+// errNotImplemented rather than silently doing nothing; Close returns nil.
+func (d *Driver) Open(id string, opts resolv.Options) resolv.Session {
+	return &session{driver: d, id: id, opts: opts}
+}
+
+type session struct {
+	driver *Driver
+	id     string
+	opts   resolv.Options
+}
+
+var errNotImplemented = fmt.Errorf("blob driver: write support not yet implemented")
+
+func (s *session) Put(lpath string, r io.Reader) error { return errNotImplemented }
+
+func (s *session) Delete(lpath string) error { return errNotImplemented }
+
+func (s *session) Move(src, dest string) error { return errNotImplemented }
+
+func (s *session) Read(lpath string) (io.Reader, error) { return nil, errNotImplemented }
+
+func (s *session) Commit() error { return errNotImplemented }
+
+func (s *session) Close() error { return nil }
@@ -0,0 +1,336 @@
+package blob
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/birkland/ocfl"
+	"github.com/birkland/ocfl/resolv"
+)
+
+// fakeStore is an in-memory Store for testing, keyed by key -> (content, etag).
+// List mimics S3's ListObjectsV2 semantics: non-recursive, "/"-delimited,
+// returning common prefixes for anything nested deeper than prefix's
+// immediate children.
+type fakeStore struct {
+	objects map[string]fakeObject
+}
+
+type fakeObject struct {
+	content []byte
+	etag    string
+}
+
+func newFakeStore() *fakeStore {
+	return &fakeStore{objects: map[string]fakeObject{}}
+}
+
+func (f *fakeStore) put(key, etag string, content []byte) {
+	f.objects[key] = fakeObject{content: content, etag: etag}
+}
+
+func (f *fakeStore) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	o, ok := f.objects[key]
+	if !ok {
+		return nil, &notFoundError{key: key}
+	}
+	return ioutil.NopCloser(bytes.NewReader(o.content)), nil
+}
+
+func (f *fakeStore) Put(ctx context.Context, key string, r io.Reader) error {
+	content, err := ioutil.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	f.objects[key] = fakeObject{content: content}
+	return nil
+}
+
+func (f *fakeStore) Head(ctx context.Context, key string) (string, error) {
+	o, ok := f.objects[key]
+	if !ok {
+		return "", &notFoundError{key: key}
+	}
+	return o.etag, nil
+}
+
+func (f *fakeStore) Delete(ctx context.Context, key string) error {
+	delete(f.objects, key)
+	return nil
+}
+
+func (f *fakeStore) List(ctx context.Context, prefix string) ([]ObjectInfo, error) {
+	if prefix != "" && !strings.HasSuffix(prefix, "/") {
+		prefix += "/"
+	}
+
+	seenPrefixes := map[string]bool{}
+	var infos []ObjectInfo
+	for key, o := range f.objects {
+		if !strings.HasPrefix(key, prefix) {
+			continue
+		}
+		rest := strings.TrimPrefix(key, prefix)
+		if i := strings.Index(rest, "/"); i >= 0 {
+			common := prefix + rest[:i+1]
+			if !seenPrefixes[common] {
+				seenPrefixes[common] = true
+				infos = append(infos, ObjectInfo{Key: common, IsPrefix: true})
+			}
+			continue
+		}
+		infos = append(infos, ObjectInfo{Key: key, ETag: o.etag})
+	}
+
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Key < infos[j].Key })
+	return infos, nil
+}
+
+type notFoundError struct{ key string }
+
+func (e *notFoundError) Error() string { return "no such key: " + e.key }
+
+// putObject stages a minimal single-version OCFL object under prefix (e.g.
+// "root/obj-1/"), with the NAMASTE declaration a real object root needs, plus
+// an inventory.json describing one file.
+func putObject(store *fakeStore, prefix, id, etag string) {
+	store.put(prefix+objectNamastePrefix+"1.0", "", nil)
+	inv := `{
+		"id": "` + id + `",
+		"digestAlgorithm": "sha512",
+		"head": "v1",
+		"manifest": {"deadbeef": ["v1/content/a.txt"]},
+		"versions": {
+			"v1": {
+				"created": "2020-01-01T00:00:00Z",
+				"user": {"name": "tester"},
+				"state": {"deadbeef": ["a.txt"]}
+			}
+		}
+	}`
+	store.put(prefix+inventoryFile, etag, []byte(inv))
+}
+
+func TestIsObjectRoot(t *testing.T) {
+	store := newFakeStore()
+	putObject(store, "root/obj-1/", "obj-1", "etag-1")
+
+	d := NewDriver("test", store, "bucket", 0)
+	entries, err := store.List(context.Background(), "root/obj-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ok, etag := d.isObjectRoot(entries, "root/obj-1/")
+	if !ok {
+		t.Fatalf("expected root/obj-1/ to be detected as an object root")
+	}
+	if etag != "etag-1" {
+		t.Fatalf("expected inventory etag %q, got %q", "etag-1", etag)
+	}
+}
+
+func TestIsObjectRootFalseForIntermediateNode(t *testing.T) {
+	store := newFakeStore()
+	putObject(store, "root/a/obj-1/", "obj-1", "etag-1")
+
+	d := NewDriver("test", store, "bucket", 0)
+	entries, err := store.List(context.Background(), "root")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if ok, _ := d.isObjectRoot(entries, "root/"); ok {
+		t.Fatalf("expected root/ (containing only the intermediate node a/) to not be an object root")
+	}
+}
+
+// collect runs a Walk for the given Select and returns every EntityRef of
+// matching type the callback was invoked with.
+func collectWalk(t *testing.T, d *Driver, sel resolv.Select, root string) []resolv.EntityRef {
+	t.Helper()
+	var got []resolv.EntityRef
+	err := d.Walk(sel, func(r resolv.EntityRef) error {
+		got = append(got, r)
+		return nil
+	}, root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return got
+}
+
+func TestWalkVisitsObject(t *testing.T) {
+	store := newFakeStore()
+	putObject(store, "root/a/obj-1/", "obj-1", "etag-1")
+	d := NewDriver("test", store, "bucket", 0)
+
+	objects := collectWalk(t, d, resolv.Select{Type: ocfl.Object}, "test://bucket/root")
+	if len(objects) != 1 || objects[0].ID != "obj-1" {
+		t.Fatalf("expected exactly one object obj-1, got %v", objects)
+	}
+}
+
+func TestWalkVisitsVersion(t *testing.T) {
+	store := newFakeStore()
+	putObject(store, "root/a/obj-1/", "obj-1", "etag-1")
+	d := NewDriver("test", store, "bucket", 0)
+
+	versions := collectWalk(t, d, resolv.Select{Type: ocfl.Version}, "test://bucket/root")
+	if len(versions) != 1 || versions[0].ID != "v1" {
+		t.Fatalf("expected exactly one version v1, got %v", versions)
+	}
+}
+
+func TestWalkVisitsFile(t *testing.T) {
+	store := newFakeStore()
+	putObject(store, "root/a/obj-1/", "obj-1", "etag-1")
+	d := NewDriver("test", store, "bucket", 0)
+
+	files := collectWalk(t, d, resolv.Select{Type: ocfl.File}, "test://bucket/root")
+	if len(files) != 1 || files[0].ID != "a.txt" {
+		t.Fatalf("expected exactly one file a.txt, got %v", files)
+	}
+}
+
+// countingStore wraps a Store and counts calls to Get, so a test can assert
+// an inventory was (or wasn't) re-fetched rather than served from the cache.
+type countingStore struct {
+	Store
+	gets int
+}
+
+func (c *countingStore) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	c.gets++
+	return c.Store.Get(ctx, key)
+}
+
+func TestWalkScopesToRequestedObject(t *testing.T) {
+	store := newFakeStore()
+	putObject(store, "root/obj-1/", "obj-1", "etag-1")
+	putObject(store, "root/obj-2/", "obj-2", "etag-2")
+	d := NewDriver("test", store, "bucket", 0)
+
+	var got []resolv.EntityRef
+	err := d.Walk(resolv.Select{Type: ocfl.Object}, func(r resolv.EntityRef) error {
+		got = append(got, r)
+		return nil
+	}, "test://bucket/root", "obj-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(got) != 1 || got[0].ID != "obj-1" {
+		t.Fatalf("expected walk scoped to obj-1 to visit only obj-1, got %v", got)
+	}
+}
+
+func TestWalkScopesToRequestedVersion(t *testing.T) {
+	store := newFakeStore()
+	putObject(store, "root/obj-1/", "obj-1", "etag-1")
+	d := NewDriver("test", store, "bucket", 0)
+
+	files := collectWalk(t, d, resolv.Select{Type: ocfl.File}, "test://bucket/root")
+	if len(files) != 1 {
+		t.Fatalf("sanity check: expected one file in fixture, got %v", files)
+	}
+
+	var got []resolv.EntityRef
+	err := d.Walk(resolv.Select{Type: ocfl.File}, func(r resolv.EntityRef) error {
+		got = append(got, r)
+		return nil
+	}, "test://bucket/root", "obj-1", "v1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 1 || got[0].ID != "a.txt" {
+		t.Fatalf("expected walk scoped to obj-1/v1 to visit a.txt, got %v", got)
+	}
+
+	got = nil
+	err = d.Walk(resolv.Select{Type: ocfl.File}, func(r resolv.EntityRef) error {
+		got = append(got, r)
+		return nil
+	}, "test://bucket/root", "obj-1", "v2-does-not-exist")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 0 {
+		t.Fatalf("expected walk scoped to a nonexistent version to visit nothing, got %v", got)
+	}
+}
+
+func TestWalkCachesInventoryAcrossRepeatWalks(t *testing.T) {
+	store := newFakeStore()
+	putObject(store, "root/obj-1/", "obj-1", "etag-1")
+	counting := &countingStore{Store: store}
+
+	d := NewDriver("test", counting, "bucket", 0)
+
+	walk := func() {
+		err := d.Walk(resolv.Select{Type: ocfl.Object}, func(r resolv.EntityRef) error { return nil }, "test://bucket/root")
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	walk()
+	walk()
+
+	if counting.gets != 1 {
+		t.Fatalf("expected inventory to be fetched once and served from cache thereafter, got %d fetches", counting.gets)
+	}
+}
+
+func TestWalkRefetchesInventoryWhenEtagChanges(t *testing.T) {
+	store := newFakeStore()
+	putObject(store, "root/obj-1/", "obj-1", "etag-1")
+	counting := &countingStore{Store: store}
+
+	d := NewDriver("test", counting, "bucket", 0)
+
+	walk := func() {
+		err := d.Walk(resolv.Select{Type: ocfl.Object}, func(r resolv.EntityRef) error { return nil }, "test://bucket/root")
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	walk()
+
+	// A new ETag (as if the object were updated out from under the cache)
+	// must invalidate the cached entry rather than reuse it.
+	putObject(store, "root/obj-1/", "obj-1", "etag-2")
+	walk()
+
+	if counting.gets != 2 {
+		t.Fatalf("expected inventory to be re-fetched after its ETag changed, got %d fetches", counting.gets)
+	}
+}
+
+func TestSessionWriteMethodsReturnNotImplemented(t *testing.T) {
+	d := NewDriver("test", newFakeStore(), "bucket", 0)
+	s := d.Open("obj-1", resolv.Options{})
+
+	if err := s.Put("a.txt", bytes.NewReader(nil)); err != errNotImplemented {
+		t.Fatalf("expected Put to return errNotImplemented, got %v", err)
+	}
+	if err := s.Delete("a.txt"); err != errNotImplemented {
+		t.Fatalf("expected Delete to return errNotImplemented, got %v", err)
+	}
+	if err := s.Move("a.txt", "b.txt"); err != errNotImplemented {
+		t.Fatalf("expected Move to return errNotImplemented, got %v", err)
+	}
+	if _, err := s.Read("a.txt"); err != errNotImplemented {
+		t.Fatalf("expected Read to return errNotImplemented, got %v", err)
+	}
+	if err := s.Commit(); err != errNotImplemented {
+		t.Fatalf("expected Commit to return errNotImplemented, got %v", err)
+	}
+	if err := s.Close(); err != nil {
+		t.Fatalf("expected Close to succeed, got %v", err)
+	}
+}
@@ -0,0 +1,91 @@
+package blob
+
+import (
+	"context"
+	"io"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// s3Store is a Store backed by a single S3 (or S3-compatible) bucket.
+type s3Store struct {
+	client *s3.Client
+	bucket string
+}
+
+// NewS3Store returns a Store backed by bucket, reached through client. The caller
+// is responsible for configuring client (region, credentials, endpoint, ...).
+func NewS3Store(client *s3.Client, bucket string) Store {
+	return &s3Store{client: client, bucket: bucket}
+}
+
+func (s *s3Store) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	out, err := s.client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return out.Body, nil
+}
+
+func (s *s3Store) Put(ctx context.Context, key string, r io.Reader) error {
+	_, err := s.client.PutObject(ctx, &s3.PutObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(key),
+		Body:   r,
+	})
+	return err
+}
+
+func (s *s3Store) Head(ctx context.Context, key string) (string, error) {
+	out, err := s.client.HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return "", err
+	}
+	return aws.ToString(out.ETag), nil
+}
+
+func (s *s3Store) Delete(ctx context.Context, key string) error {
+	_, err := s.client.DeleteObject(ctx, &s3.DeleteObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(key),
+	})
+	return err
+}
+
+// List enumerates objects and common prefixes immediately beneath prefix, using
+// "/" as the delimiter, paging through ListObjectsV2 as needed.
+func (s *s3Store) List(ctx context.Context, prefix string) ([]ObjectInfo, error) {
+	if prefix != "" && !strings.HasSuffix(prefix, "/") {
+		prefix += "/"
+	}
+
+	var infos []ObjectInfo
+	paginator := s3.NewListObjectsV2Paginator(s.client, &s3.ListObjectsV2Input{
+		Bucket:    aws.String(s.bucket),
+		Prefix:    aws.String(prefix),
+		Delimiter: aws.String("/"),
+	})
+
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, p := range page.CommonPrefixes {
+			infos = append(infos, ObjectInfo{Key: aws.ToString(p.Prefix), IsPrefix: true})
+		}
+		for _, o := range page.Contents {
+			infos = append(infos, ObjectInfo{Key: aws.ToString(o.Key), ETag: aws.ToString(o.ETag)})
+		}
+	}
+
+	return infos, nil
+}
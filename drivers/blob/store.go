@@ -0,0 +1,34 @@
+// Package blob implements resolv.Driver against an object store (S3, GCS, Azure, ...)
+// addressed by opaque URIs, rather than a local filesystem.
+package blob
+
+import (
+	"context"
+	"io"
+)
+
+// ObjectInfo describes a single entry returned by Store.List: either an object, or
+// a common prefix one delimiter ("/") deeper than the prefix that was listed.
+type ObjectInfo struct {
+	Key      string // Full key (or, for a common prefix, the prefix itself, ending in "/").
+	ETag     string // Entity tag of the object; empty for a common prefix.
+	IsPrefix bool   // True if Key is a common prefix rather than an object.
+}
+
+// Store abstracts the handful of object-storage operations the blob driver needs,
+// so that additional backends can be added without touching driver.go or the walk
+// logic. Implementations are expected to behave like S3's ListObjectsV2: List is
+// non-recursive and delimiter-based, returning common prefixes for anything nested
+// deeper than prefix's immediate children.
+type Store interface {
+	// Get returns the content of key.
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	// Put writes r's content to key, replacing anything already there.
+	Put(ctx context.Context, key string, r io.Reader) error
+	// Head returns key's current ETag without fetching its content.
+	Head(ctx context.Context, key string) (etag string, err error)
+	// Delete removes key.
+	Delete(ctx context.Context, key string) error
+	// List enumerates objects and common prefixes immediately under prefix.
+	List(ctx context.Context, prefix string) ([]ObjectInfo, error)
+}
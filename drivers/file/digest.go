@@ -0,0 +1,59 @@
+package file
+
+import (
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+)
+
+// digestAlgorithms constructs the hash.Hash for each OCFL digest algorithm this
+// driver knows how to compute. "sha512" is OCFL's required default.
+var digestAlgorithms = map[string]func() hash.Hash{
+	"sha512": sha512.New,
+	"sha256": sha256.New,
+}
+
+// digesters streams a single write through one hash.Hash per requested algorithm,
+// so Put can compute every configured digest in one pass over the content instead
+// of re-reading it once per algorithm.
+type digesters struct {
+	hashes map[string]hash.Hash
+}
+
+func newDigesters(algorithms []string) (*digesters, error) {
+	if len(algorithms) == 0 {
+		algorithms = []string{"sha512"}
+	}
+
+	d := &digesters{hashes: make(map[string]hash.Hash, len(algorithms))}
+	for _, alg := range algorithms {
+		ctor, ok := digestAlgorithms[alg]
+		if !ok {
+			return nil, fmt.Errorf("unsupported digest algorithm %q", alg)
+		}
+		d.hashes[alg] = ctor()
+	}
+	return d, nil
+}
+
+// Writer returns an io.Writer that feeds every configured hash.Hash, suitable
+// for use as (one of) the destinations of an io.MultiWriter.
+func (d *digesters) Writer() io.Writer {
+	writers := make([]io.Writer, 0, len(d.hashes))
+	for _, h := range d.hashes {
+		writers = append(writers, h)
+	}
+	return io.MultiWriter(writers...)
+}
+
+// Sums returns the hex-encoded digest computed so far for every configured algorithm.
+func (d *digesters) Sums() map[string]string {
+	sums := make(map[string]string, len(d.hashes))
+	for alg, h := range d.hashes {
+		sums[alg] = hex.EncodeToString(h.Sum(nil))
+	}
+	return sums
+}
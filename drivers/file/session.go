@@ -0,0 +1,574 @@
+package file
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/birkland/ocfl"
+	"github.com/birkland/ocfl/metadata"
+	"github.com/birkland/ocfl/resolv"
+	"github.com/pkg/errors"
+)
+
+const (
+	stagingDir = ".ocfl-staging"
+	lockFile   = ".ocfl-lock"
+	contentDir = "content"
+)
+
+// pendingMove records that the content currently at src should appear at dest in
+// the committed version; src is still removed from the new version's state.
+type pendingMove struct {
+	src, dest string
+}
+
+// session implements resolv.Session against a single OCFL object rooted at
+// objectPath. OCFL versions are immutable once committed, so Put, Delete, and
+// Move never touch a prior version directory - they only record intent, and
+// stage new content, under <objectPath>/.ocfl-staging/<id>/. Commit is what
+// actually materializes the new version, atomically, from that staging area.
+type session struct {
+	sessionID  string // Identifies this session's staging area; distinct from the object's logical ID.
+	objectID   string // The OCFL object's logical ID, as passed to Opener.Open. Never changes across versions.
+	objectPath string
+	opts       resolv.Options
+	authz      resolv.Authorizer
+
+	lock *os.File // held for the session's lifetime via flock(2); guards one uncommitted session per object
+
+	mu        sync.Mutex
+	puts      map[string]string            // logical path -> staged content file
+	digests   map[string]map[string]string // logical path -> algorithm -> digest, for staged puts
+	deletes   map[string]bool              // logical path -> deleted
+	moves     []pendingMove
+	closed    bool
+	committed bool
+}
+
+// openSession starts a new session against the object identified by objectID,
+// rooted at objectPath, acquiring its lock file so only one uncommitted
+// session can exist at a time. sessionID need only be unique per concurrent
+// session on this object; it has no bearing on the committed inventory.
+func openSession(objectPath, objectID, sessionID string, opts resolv.Options, authz resolv.Authorizer) (*session, error) {
+	// Opening a session stages nothing and reads nothing by itself; each
+	// operation (Put, Delete, Move, Read, Commit) checks the permission it
+	// actually needs, so a Read-only principal can still open a session to
+	// call Read, and a Write-only principal can't Delete.
+	if err := os.MkdirAll(objectPath, 0755); err != nil {
+		return nil, errors.Wrapf(err, "creating object root %s", objectPath)
+	}
+
+	lock, err := os.OpenFile(filepath.Join(objectPath, lockFile), os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return nil, errors.Wrapf(err, "opening lock file for %s", objectPath)
+	}
+	if err := syscall.Flock(int(lock.Fd()), syscall.LOCK_EX|syscall.LOCK_NB); err != nil {
+		lock.Close()
+		return nil, errors.Wrapf(err, "object %s already has an uncommitted session", objectPath)
+	}
+
+	staging := filepath.Join(objectPath, stagingDir, sessionID, contentDir)
+	if err := os.MkdirAll(staging, 0755); err != nil {
+		syscall.Flock(int(lock.Fd()), syscall.LOCK_UN)
+		lock.Close()
+		return nil, errors.Wrapf(err, "creating staging area for session %s", sessionID)
+	}
+
+	return &session{
+		sessionID:  sessionID,
+		objectID:   objectID,
+		objectPath: objectPath,
+		opts:       opts,
+		authz:      authz,
+		lock:       lock,
+		puts:       map[string]string{},
+		digests:    map[string]map[string]string{},
+		deletes:    map[string]bool{},
+	}, nil
+}
+
+// checkPermission consults s.authz (if configured) for need on this session's
+// object, returning a *resolv.PermissionDenied if the principal lacks it.
+func (s *session) checkPermission(need resolv.Permission) error {
+	if s.authz == nil {
+		return nil
+	}
+	ref := resolv.EntityRef{Type: ocfl.Object, ID: s.objectID, Addr: s.objectPath}
+	ok, err := s.authz.HasPermission(s.opts.User, ref, need)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return &resolv.PermissionDenied{User: s.opts.User, Ref: ref, Need: need}
+	}
+	return nil
+}
+
+func (s *session) stagingRoot() string {
+	return filepath.Join(s.objectPath, stagingDir, s.sessionID)
+}
+
+func (s *session) stagingContent(lpath string) string {
+	return filepath.Join(s.stagingRoot(), contentDir, filepath.FromSlash(lpath))
+}
+
+// Put stages r's content as lpath, computing every digest algorithm configured
+// in s.opts.DigestAlgorithms in a single streaming pass.
+func (s *session) Put(lpath string, r io.Reader) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return fmt.Errorf("session %s is closed", s.sessionID)
+	}
+	if err := s.checkPermission(resolv.Write); err != nil {
+		return err
+	}
+
+	dest := s.stagingContent(lpath)
+	if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+		return errors.Wrapf(err, "staging directory for %s", lpath)
+	}
+
+	tmp, err := ioutil.TempFile(filepath.Dir(dest), ".put-*")
+	if err != nil {
+		return errors.Wrap(err, "creating staging temp file")
+	}
+	defer os.Remove(tmp.Name())
+
+	d, err := newDigesters(s.opts.DigestAlgorithms)
+	if err != nil {
+		tmp.Close()
+		return err
+	}
+
+	if _, err := io.Copy(io.MultiWriter(tmp, d.Writer()), r); err != nil {
+		tmp.Close()
+		return errors.Wrapf(err, "writing staged content for %s", lpath)
+	}
+	if err := tmp.Close(); err != nil {
+		return errors.Wrap(err, "closing staged content")
+	}
+
+	if err := os.Rename(tmp.Name(), dest); err != nil {
+		return errors.Wrapf(err, "staging content for %s", lpath)
+	}
+
+	s.puts[lpath] = dest
+	s.digests[lpath] = d.Sums()
+	delete(s.deletes, lpath)
+	s.moves = removeMovesTo(s.moves, lpath)
+	return nil
+}
+
+// Delete records lpath as removed from the version being built by this
+// session. It takes effect at Commit; no prior version is touched.
+func (s *session) Delete(lpath string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return fmt.Errorf("session %s is closed", s.sessionID)
+	}
+	if err := s.checkPermission(resolv.Delete); err != nil {
+		return err
+	}
+
+	delete(s.puts, lpath)
+	delete(s.digests, lpath)
+	s.moves = removeMovesTo(s.moves, lpath)
+	s.deletes[lpath] = true
+	return nil
+}
+
+// Move records that content currently at src should appear at dest in the
+// version being built by this session. Like Put and Delete, it only records
+// intent until Commit.
+func (s *session) Move(src, dest string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return fmt.Errorf("session %s is closed", s.sessionID)
+	}
+	// Move both removes content from src and adds it at dest, so it requires
+	// whatever Delete and Put each individually require.
+	if err := s.checkPermission(resolv.Write | resolv.Delete); err != nil {
+		return err
+	}
+
+	if staged, ok := s.puts[src]; ok {
+		// The source was staged in this same session; just rename the staged file.
+		delete(s.puts, src)
+		digests := s.digests[src]
+		delete(s.digests, src)
+
+		newDest := s.stagingContent(dest)
+		if err := os.MkdirAll(filepath.Dir(newDest), 0755); err != nil {
+			return err
+		}
+		if err := os.Rename(staged, newDest); err != nil {
+			return errors.Wrapf(err, "moving staged content from %s to %s", src, dest)
+		}
+
+		s.puts[dest] = newDest
+		s.digests[dest] = digests
+		delete(s.deletes, dest)
+		return nil
+	}
+
+	delete(s.deletes, dest)
+	s.moves = append(removeMovesTo(s.moves, dest), pendingMove{src: src, dest: dest})
+	return nil
+}
+
+func removeMovesTo(moves []pendingMove, dest string) []pendingMove {
+	kept := moves[:0]
+	for _, m := range moves {
+		if m.dest != dest {
+			kept = append(kept, m)
+		}
+	}
+	return kept
+}
+
+// movedAway reports whether lpath is the source of a pending Move recorded
+// against a prior version's content, i.e. it should no longer be readable at
+// its old logical path even though it isn't in s.deletes.
+func (s *session) movedAway(lpath string) bool {
+	for _, m := range s.moves {
+		if m.src == lpath {
+			return true
+		}
+	}
+	return false
+}
+
+// Read returns content at lpath, preferring anything staged in this session
+// (a Put not yet committed) over the parent version's manifest.
+func (s *session) Read(lpath string) (io.Reader, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.checkPermission(resolv.Read); err != nil {
+		return nil, err
+	}
+
+	if s.deletes[lpath] || s.movedAway(lpath) {
+		return nil, fmt.Errorf("%s was deleted in this session", lpath)
+	}
+	if staged, ok := s.puts[lpath]; ok {
+		return os.Open(staged)
+	}
+
+	// Prior-version content moved in this session is read from its source path.
+	src := lpath
+	for i := len(s.moves) - 1; i >= 0; i-- {
+		if s.moves[i].dest == src {
+			src = s.moves[i].src
+		}
+	}
+
+	inv, err := readMetadata(s.objectPath)
+	if err != nil {
+		return nil, fmt.Errorf("%s does not exist", lpath)
+	}
+
+	head, err := headVersion(inv)
+	if err != nil {
+		return nil, err
+	}
+
+	files, err := inv.Files(head)
+	if err != nil {
+		return nil, err
+	}
+	for _, file := range files {
+		if file.LogicalPath == src {
+			return os.Open(filepath.Join(s.objectPath, file.PhysicalPath))
+		}
+	}
+
+	return nil, fmt.Errorf("%s does not exist", lpath)
+}
+
+// Commit atomically materializes a new object version from everything staged
+// in this session:
+//  1. the new inventory (manifest + state + user + created) is written into the
+//     staging area first,
+//  2. the staging area (now containing both the version's content and its
+//     inventory) is renamed into place as the new vN directory in one step, and
+//  3. the new inventory is copied up to the object root.
+func (s *session) Commit() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return fmt.Errorf("session %s is closed", s.sessionID)
+	}
+	if err := s.checkPermission(resolv.Write); err != nil {
+		return err
+	}
+
+	inv, err := readMetadata(s.objectPath)
+	var head string
+	state := map[string]string{} // logical path -> digest
+	manifest := map[string][]string{}
+	if err == nil {
+		head, err = headVersion(inv)
+		if err != nil {
+			return err
+		}
+		files, err := inv.Files(head)
+		if err != nil {
+			return err
+		}
+		for _, file := range files {
+			state[file.LogicalPath] = file.Digest
+			manifest[file.Digest] = append(manifest[file.Digest], file.PhysicalPath)
+		}
+	} else {
+		inv = &metadata.Inventory{ID: s.objectID}
+	}
+
+	nextVersion, err := nextVersionID(head)
+	if err != nil {
+		return err
+	}
+
+	// Moves are applied before deletes, and independently of s.deletes: a Move's
+	// source is never recorded as a delete, so it can't be removed from state
+	// out from under the lookup below before it's relocated to its destination.
+	for _, m := range s.moves {
+		if digest, ok := state[m.src]; ok {
+			delete(state, m.src)
+			state[m.dest] = digest
+		}
+	}
+	for lpath := range s.deletes {
+		delete(state, lpath)
+	}
+	for lpath, sums := range s.digests {
+		digest := sums[primaryAlgorithm(s.opts.DigestAlgorithms)]
+		physical := nextVersion + "/" + contentDir + "/" + lpath
+		state[lpath] = digest
+		manifest[digest] = append(manifest[digest], physical)
+	}
+
+	if err := s.writeInventory(nextVersion, state, manifest); err != nil {
+		return err
+	}
+
+	versionPath := filepath.Join(s.objectPath, nextVersion)
+	if err := os.Rename(s.stagingRoot(), versionPath); err != nil {
+		return errors.Wrapf(err, "materializing version %s", nextVersion)
+	}
+
+	for _, alg := range s.inventoryAlgorithms() {
+		if err := copyFile(
+			filepath.Join(versionPath, "inventory.json"+sidecarSuffix(alg)),
+			filepath.Join(s.objectPath, "inventory.json"+sidecarSuffix(alg)),
+		); err != nil {
+			return errors.Wrap(err, "copying inventory sidecar to object root")
+		}
+	}
+	if err := copyFile(filepath.Join(versionPath, "inventory.json"), filepath.Join(s.objectPath, "inventory.json")); err != nil {
+		return errors.Wrap(err, "copying inventory.json to object root")
+	}
+
+	s.committed = true
+	return s.release()
+}
+
+// Close ends the session. If Commit was never called, everything staged is
+// discarded by removing the session's staging directory; the shared
+// .ocfl-staging directory and the .ocfl-lock file are left in place.
+func (s *session) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return nil
+	}
+
+	if !s.committed {
+		if err := os.RemoveAll(s.stagingRoot()); err != nil {
+			return errors.Wrap(err, "removing staging area")
+		}
+	}
+
+	return s.release()
+}
+
+// release unlocks and closes the session's lock file. Safe to call once,
+// after either a successful Commit or an uncommitted Close.
+func (s *session) release() error {
+	if s.closed {
+		return nil
+	}
+	s.closed = true
+
+	if err := syscall.Flock(int(s.lock.Fd()), syscall.LOCK_UN); err != nil {
+		s.lock.Close()
+		return errors.Wrap(err, "releasing object lock")
+	}
+	return s.lock.Close()
+}
+
+func (s *session) inventoryAlgorithms() []string {
+	algs := s.opts.DigestAlgorithms
+	if len(algs) == 0 {
+		algs = []string{"sha512"}
+	}
+	return algs
+}
+
+// writeInventory writes inventory.json and its digest sidecars into the staging
+// area, so they're already present when the staging area is renamed into place
+// as the new version directory.
+func (s *session) writeInventory(version string, state map[string]string, manifest map[string][]string) error {
+	doc := inventoryDoc{
+		ID:              s.objectID,
+		Type:            "https://ocfl.io/1.0/spec/#inventory",
+		DigestAlgorithm: primaryAlgorithm(s.opts.DigestAlgorithms),
+		Head:            version,
+		Manifest:        manifest,
+		Versions: map[string]inventoryVersion{
+			version: {
+				Created: time.Now().UTC().Format(time.RFC3339),
+				Message: "",
+				User:    inventoryUser{Name: s.opts.User.Name, Address: s.opts.User.Address},
+				State:   invertState(state),
+			},
+		},
+	}
+
+	raw, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return errors.Wrap(err, "marshaling inventory")
+	}
+
+	path := filepath.Join(s.stagingRoot(), "inventory.json")
+	if err := ioutil.WriteFile(path, raw, 0644); err != nil {
+		return errors.Wrap(err, "writing staged inventory.json")
+	}
+
+	for _, alg := range s.inventoryAlgorithms() {
+		d, err := newDigesters([]string{alg})
+		if err != nil {
+			return err
+		}
+		if _, err := d.Writer().Write(raw); err != nil {
+			return err
+		}
+		sidecar := fmt.Sprintf("%s  inventory.json\n", d.Sums()[alg])
+		if err := ioutil.WriteFile(path+sidecarSuffix(alg), []byte(sidecar), 0644); err != nil {
+			return errors.Wrapf(err, "writing inventory.json%s", sidecarSuffix(alg))
+		}
+	}
+
+	return nil
+}
+
+func sidecarSuffix(alg string) string {
+	return "." + alg
+}
+
+// inventoryDoc, inventoryVersion, and inventoryUser mirror the subset of the
+// OCFL inventory.json structure this driver writes. Reading back a committed
+// inventory goes through metadata.Inventory (see readMetadata), not these types.
+type inventoryDoc struct {
+	ID              string                      `json:"id"`
+	Type            string                      `json:"type"`
+	DigestAlgorithm string                      `json:"digestAlgorithm"`
+	Head            string                      `json:"head"`
+	Manifest        map[string][]string         `json:"manifest"`
+	Versions        map[string]inventoryVersion `json:"versions"`
+}
+
+type inventoryVersion struct {
+	Created string              `json:"created"`
+	Message string              `json:"message,omitempty"`
+	User    inventoryUser       `json:"user"`
+	State   map[string][]string `json:"state"`
+}
+
+type inventoryUser struct {
+	Name    string `json:"name"`
+	Address string `json:"address,omitempty"`
+}
+
+// invertState turns a logical-path -> digest map into OCFL's digest -> []logicalPath
+// state representation.
+func invertState(state map[string]string) map[string][]string {
+	inverted := map[string][]string{}
+	for lpath, digest := range state {
+		inverted[digest] = append(inverted[digest], lpath)
+	}
+	return inverted
+}
+
+// headVersion returns the highest "vN" version ID recorded in inv.
+func headVersion(inv *metadata.Inventory) (string, error) {
+	best := 0
+	for vID := range inv.Versions {
+		n, err := versionNumber(vID)
+		if err != nil {
+			return "", err
+		}
+		if n > best {
+			best = n
+		}
+	}
+	if best == 0 {
+		return "", fmt.Errorf("object has no versions")
+	}
+	return fmt.Sprintf("v%d", best), nil
+}
+
+// nextVersionID returns the version ID that should follow head ("" meaning this
+// object has no versions yet, so the first version is v1).
+func nextVersionID(head string) (string, error) {
+	if head == "" {
+		return "v1", nil
+	}
+	n, err := versionNumber(head)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("v%d", n+1), nil
+}
+
+func versionNumber(vID string) (int, error) {
+	n, err := strconv.Atoi(strings.TrimPrefix(vID, "v"))
+	if err != nil {
+		return 0, fmt.Errorf("malformed version id %q", vID)
+	}
+	return n, nil
+}
+
+// primaryAlgorithm returns the algorithm recorded as an object's digestAlgorithm:
+// the first of the configured DigestAlgorithms, defaulting to OCFL's "sha512".
+func primaryAlgorithm(algs []string) string {
+	if len(algs) == 0 {
+		return "sha512"
+	}
+	return algs[0]
+}
+
+func copyFile(src, dest string) error {
+	raw, err := ioutil.ReadFile(src)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(dest, raw, 0644)
+}
@@ -0,0 +1,111 @@
+package file
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/birkland/ocfl/resolv"
+)
+
+// fakeAuthorizer grants a fixed set of permissions to every principal, on
+// every entity, regardless of coordinates - enough to exercise which
+// permission a session operation checks, without needing a real grants store.
+type fakeAuthorizer struct {
+	granted resolv.Permission
+}
+
+func (a *fakeAuthorizer) HasPermission(user resolv.Principal, ref resolv.EntityRef, need resolv.Permission) (bool, error) {
+	return a.granted&need == need, nil
+}
+
+func asPermissionDenied(t *testing.T, err error) *resolv.PermissionDenied {
+	t.Helper()
+	var denied *resolv.PermissionDenied
+	if !errors.As(err, &denied) {
+		t.Fatalf("expected a *resolv.PermissionDenied, got %v", err)
+	}
+	return denied
+}
+
+func TestPutDeniedForReadOnlyPrincipal(t *testing.T) {
+	root := newTestObject(t)
+	authz := &fakeAuthorizer{granted: resolv.Read}
+
+	s, err := openSession(root, "test-object", "session-1", testOptions(), authz)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = s.Put("b.txt", bytes.NewReader([]byte("world")))
+	denied := asPermissionDenied(t, err)
+	if denied.Need != resolv.Write {
+		t.Fatalf("expected Put to require Write, denial reported %s", denied.Need)
+	}
+}
+
+func TestDeleteDeniedForWriteOnlyPrincipal(t *testing.T) {
+	root := newTestObject(t)
+	authz := &fakeAuthorizer{granted: resolv.Write}
+
+	s, err := openSession(root, "test-object", "session-1", testOptions(), authz)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = s.Delete("a.txt")
+	denied := asPermissionDenied(t, err)
+	if denied.Need != resolv.Delete {
+		t.Fatalf("expected Delete to require Delete, denial reported %s", denied.Need)
+	}
+}
+
+func TestReadDeniedWithoutReadPermission(t *testing.T) {
+	root := newTestObject(t)
+	authz := &fakeAuthorizer{granted: resolv.Write | resolv.Delete}
+
+	s, err := openSession(root, "test-object", "session-1", testOptions(), authz)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = s.Read("a.txt")
+	asPermissionDenied(t, err)
+}
+
+func TestMoveDeniedWithoutBothWriteAndDelete(t *testing.T) {
+	root := newTestObject(t)
+	authz := &fakeAuthorizer{granted: resolv.Write}
+
+	s, err := openSession(root, "test-object", "session-1", testOptions(), authz)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.Move("a.txt", "renamed.txt"); err == nil {
+		t.Fatal("expected Move to be denied with only Write granted")
+	}
+}
+
+func TestPutReadDeleteMoveAllowedWithFullPermissions(t *testing.T) {
+	root := newTestObject(t)
+	authz := &fakeAuthorizer{granted: resolv.Read | resolv.Write | resolv.Delete}
+
+	s, err := openSession(root, "test-object", "session-1", testOptions(), authz)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.Put("b.txt", bytes.NewReader([]byte("world"))); err != nil {
+		t.Fatalf("expected Put to succeed with Write granted: %v", err)
+	}
+	if _, err := s.Read("a.txt"); err != nil {
+		t.Fatalf("expected Read to succeed with Read granted: %v", err)
+	}
+	if err := s.Move("a.txt", "renamed.txt"); err != nil {
+		t.Fatalf("expected Move to succeed with Write and Delete granted: %v", err)
+	}
+	if err := s.Delete("b.txt"); err != nil {
+		t.Fatalf("expected Delete to succeed with Delete granted: %v", err)
+	}
+}
@@ -0,0 +1,181 @@
+package file
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/birkland/ocfl/resolv"
+)
+
+// newTestObject lays out a minimal, already-committed v1 OCFL object so Commit
+// has a prior version to build on and to check remains untouched afterward.
+func newTestObject(t *testing.T) string {
+	t.Helper()
+
+	root, err := ioutil.TempDir("", "ocfl-session")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(root) })
+
+	v1content := filepath.Join(root, "v1", "content")
+	if err := os.MkdirAll(v1content, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(v1content, "a.txt"), []byte("hello"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	inv := `{
+		"id": "test-object",
+		"type": "https://ocfl.io/1.0/spec/#inventory",
+		"digestAlgorithm": "sha512",
+		"head": "v1",
+		"manifest": {"deadbeef": ["v1/content/a.txt"]},
+		"versions": {
+			"v1": {
+				"created": "2020-01-01T00:00:00Z",
+				"user": {"name": "tester"},
+				"state": {"deadbeef": ["a.txt"]}
+			}
+		}
+	}`
+	if err := ioutil.WriteFile(filepath.Join(root, "inventory.json"), []byte(inv), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(root, "v1", "inventory.json"), []byte(inv), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	return root
+}
+
+func TestCommitLeavesPriorVersionByteIdentical(t *testing.T) {
+	root := newTestObject(t)
+
+	before, err := ioutil.ReadFile(filepath.Join(root, "v1", "content", "a.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := openSession(root, "test-object", "session-1", testOptions(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.Put("b.txt", bytes.NewReader([]byte("world"))); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.Commit(); err != nil {
+		t.Fatal(err)
+	}
+
+	after, err := ioutil.ReadFile(filepath.Join(root, "v1", "content", "a.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(before, after) {
+		t.Fatalf("v1/content/a.txt changed after commit: %q -> %q", before, after)
+	}
+
+	got, err := ioutil.ReadFile(filepath.Join(root, "v2", "content", "b.txt"))
+	if err != nil {
+		t.Fatalf("expected v2/content/b.txt to exist: %v", err)
+	}
+	if string(got) != "world" {
+		t.Fatalf("unexpected v2 content: %q", got)
+	}
+
+	raw, err := ioutil.ReadFile(filepath.Join(root, "inventory.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var doc struct {
+		ID string `json:"id"`
+	}
+	if err := json.Unmarshal(raw, &doc); err != nil {
+		t.Fatal(err)
+	}
+	if doc.ID != "test-object" {
+		t.Fatalf("expected inventory id to remain %q, got %q", "test-object", doc.ID)
+	}
+}
+
+func TestCommitMoveOfPreExistingFile(t *testing.T) {
+	root := newTestObject(t)
+
+	s, err := openSession(root, "test-object", "session-1", testOptions(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.Move("a.txt", "renamed.txt"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.Commit(); err != nil {
+		t.Fatal(err)
+	}
+
+	raw, err := ioutil.ReadFile(filepath.Join(root, "inventory.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var doc struct {
+		Versions map[string]struct {
+			State map[string][]string `json:"state"`
+		} `json:"versions"`
+	}
+	if err := json.Unmarshal(raw, &doc); err != nil {
+		t.Fatal(err)
+	}
+
+	state := doc.Versions["v2"].State
+	paths := state["deadbeef"]
+	if len(paths) != 1 || paths[0] != "renamed.txt" {
+		t.Fatalf("expected digest deadbeef to map only to renamed.txt, got %v", paths)
+	}
+	for digest, lpaths := range state {
+		for _, lpath := range lpaths {
+			if lpath == "a.txt" {
+				t.Fatalf("a.txt should no longer be present in v2 state, found under digest %s", digest)
+			}
+		}
+	}
+}
+
+func TestCloseWithoutCommitDiscardsStaging(t *testing.T) {
+	root := newTestObject(t)
+
+	s, err := openSession(root, "test-object", "session-1", testOptions(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.Put("b.txt", bytes.NewReader([]byte("world"))); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := os.Stat(filepath.Join(root, stagingDir, "session-1")); !os.IsNotExist(err) {
+		t.Fatalf("expected staging area to be removed, stat err: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(root, "v2")); !os.IsNotExist(err) {
+		t.Fatalf("expected no v2 to have been materialized")
+	}
+}
+
+func testOptions() resolv.Options {
+	return resolv.Options{
+		User: resolv.Principal{Name: "tester"},
+	}
+}
@@ -1,10 +1,13 @@
 package file
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"path/filepath"
+	"runtime"
 	"strings"
+	"sync"
 
 	"github.com/birkland/ocfl"
 	"github.com/birkland/ocfl/metadata"
@@ -20,29 +23,37 @@ const (
 
 // Scope defines a bounded set of OCFL entries (e.g. everything under a given root)
 type scope struct {
-	root      *resolv.EntityRef
-	startFrom *resolv.EntityRef
-	desired   *resolv.EntityRef
+	root        *resolv.EntityRef
+	startFrom   *resolv.EntityRef
+	desired     *resolv.EntityRef
+	concurrency int               // Number of goroutines fanned out over per-object walks.  0 means GOMAXPROCS.
+	overlay     *resolv.Overlay   // Staged changes to present as merged with on-disk content, if any.
+	authz       resolv.Authorizer // If set, entities principal cannot Stat are silently skipped.
+	principal   resolv.Principal  // The user a Walk is performed as, checked against authz.
 }
 
 // NewScope defines a scope for ocfl entities underneath the given parent entity
 // Logical choices for a parent include an OCFL root, an ocfl object, or
 // an ocfl version.
-func newScope(under *resolv.EntityRef, t ocfl.Type) (*scope, error) {
+func newScope(under *resolv.EntityRef, sel resolv.Select, overlay *resolv.Overlay, authz resolv.Authorizer, principal resolv.Principal) (*scope, error) {
 	root, err := findRoot(under, ocfl.Root)
 	if err != nil {
 		return nil, err
 	}
 
-	desired := &resolv.EntityRef{Type: t}
-	if under.Type == t {
+	desired := &resolv.EntityRef{Type: sel.Type}
+	if under.Type == sel.Type {
 		desired = under
 	}
 
 	return &scope{
-		root:      root,
-		startFrom: under,
-		desired:   desired,
+		root:        root,
+		startFrom:   under,
+		desired:     desired,
+		concurrency: sel.Concurrency,
+		overlay:     overlay,
+		authz:       authz,
+		principal:   principal,
 	}, nil
 }
 
@@ -51,7 +62,16 @@ func newScope(under *resolv.EntityRef, t ocfl.Type) (*scope, error) {
 // (a) when starting from an ocfl root or intermediate node, walk directories until an object root is found
 // (b) walk the entities in an object (versions, files) using data from the manifest rather than the filesystem
 //
-// TODO: make this parallel!
+// Step (a) runs on the calling goroutine, since it is a single-pass directory scan, but every
+// object root it discovers is independent of every other one, so step (b) is fanned out to a
+// bounded pool of worker goroutines (sized by s.concurrency, 0 meaning GOMAXPROCS) that drain
+// discovered object roots off a channel and walk their manifests concurrently.
+//
+// The caller-supplied callback f may therefore be invoked from many goroutines. walk serializes
+// those invocations itself with an internal mutex, so f is never called concurrently with itself
+// and never needs to be safe for concurrent use - but this also means f should not block for long,
+// or it will stall every other in-flight object walk. The first error returned by f, or encountered
+// during the scan, cancels the walk and is returned once all in-flight workers have wound down.
 func (s *scope) walk(f func(resolv.EntityRef) error) error {
 	node := s.startFrom
 	fmt.Println("Walking")
@@ -66,9 +86,26 @@ func (s *scope) walk(f func(resolv.EntityRef) error) error {
 		}
 	}
 
+	var mu sync.Mutex // serializes all invocations of the caller-supplied callback
+	cb := func(r resolv.EntityRef) error {
+		mu.Lock()
+		defer mu.Unlock()
+
+		if s.authz != nil {
+			ok, err := s.authz.HasPermission(s.principal, r, resolv.Stat)
+			if err != nil {
+				return err
+			}
+			if !ok {
+				return nil // silently skip entities the principal can't Stat
+			}
+		}
+
+		return f(r)
+	}
+
 	if node.Type == ocfl.Root && s.contains(*node) {
-		err := f(*node)
-		if err != nil {
+		if err := cb(*node); err != nil {
 			return err
 		}
 	}
@@ -78,51 +115,200 @@ func (s *scope) walk(f func(resolv.EntityRef) error) error {
 		startPath = s.root.Addr
 	}
 
-	// At this point, node points to an ocfl root, intermediate node, or an ocfl object root
-	err := fsWalk(startPath, func(ospath string, e *godirwalk.Dirent) (bool, error) {
+	concurrency := s.concurrency
+	if concurrency <= 0 {
+		concurrency = runtime.GOMAXPROCS(0)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var (
+		failOnce sync.Once
+		firstErr error
+	)
+	fail := func(err error) {
+		failOnce.Do(func() {
+			firstErr = err
+			cancel()
+		})
+	}
+
+	roots := make(chan string)
+	visitedObjects := &visitedSet{}
+	var workers sync.WaitGroup
+	for i := 0; i < concurrency; i++ {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			for objectRoot := range roots {
+				if ctx.Err() != nil {
+					continue // drain the channel without doing more work once cancelled
+				}
+				if err := s.walkObject(objectRoot, cb, visitedObjects); err != nil {
+					fail(err)
+				}
+			}
+		}()
+	}
+
+	// At this point, node points to an ocfl root, intermediate node, or an ocfl object root.
+	// This scan only ever dispatches object roots to the worker pool above; it never walks
+	// into an object's manifest itself.
+	scanErr := fsWalk(startPath, func(ospath string, e *godirwalk.Dirent) (bool, error) {
+		// A worker has already failed: stop scanning instead of visiting the rest of the tree.
+		if ctx.Err() != nil {
+			return dontGoDeeper, ctx.Err()
+		}
 
 		// We dont' care about regular files
 		if !e.IsDir() && !e.IsSymlink() {
 			return dontGoDeeper, nil
 		}
 
-		// An object?  If so, walk its manifest instead of the files under it
-		if objectRoot, _, err := isRoot(ospath, ocfl.Object); objectRoot && err == nil {
+		relID := strings.TrimPrefix(filepath.ToSlash(strings.TrimPrefix(ospath, s.root.Addr)), "/")
 
-			return dontGoDeeper, s.walkObject(ospath, f)
+		// An object?  If so, hand it off to a worker to walk its manifest
+		// instead of the files under it. Whether this object is tombstoned in the
+		// overlay, and what logical ID to record as visited, can only be decided once
+		// its manifest is read: the on-disk scan path need not match the object's
+		// logical ID (e.g. under a hashed or pairtree storage layout), so that
+		// decision is deferred to walkObject rather than made here.
+		if objectRoot, _, err := isRoot(ospath, ocfl.Object); objectRoot && err == nil {
+			select {
+			case roots <- ospath:
+			case <-ctx.Done():
+			}
+			return dontGoDeeper, nil
 		} else if err != nil {
 			return dontGoDeeper, err
 		}
 
+		// A tombstoned intermediate node is skipped entirely, and not descended into.
+		// Object roots are excluded from this check above: their logical coordinate
+		// comes from the manifest, not the scan path.
+		if s.overlay.Removed(strings.Split(relID, "/")) {
+			return dontGoDeeper, nil
+		}
+
 		// Skip root, process intermdiate and continue
 		if ospath != s.root.Addr && s.contains(resolv.EntityRef{Type: ocfl.Intermediate}) {
-			err := f(resolv.EntityRef{
-				ID:     strings.TrimPrefix(filepath.ToSlash(strings.TrimPrefix(ospath, s.root.Addr)), "/"),
+			if err := cb(resolv.EntityRef{
+				ID:     relID,
 				Addr:   ospath,
 				Type:   ocfl.Intermediate,
 				Parent: s.root,
-			})
-			if err != nil {
+			}); err != nil {
 				return dontGoDeeper, err
 			}
 		}
 
 		return goDeeper, nil
 	})
-	if err != nil {
-		return errors.Wrapf(err, "error performing walk")
+	close(roots)
+	workers.Wait()
+
+	if scanErr != nil {
+		fail(errors.Wrapf(scanErr, "error performing walk"))
+	}
+	if firstErr != nil {
+		return firstErr
+	}
+
+	// Surface object IDs that exist only in the overlay (staged, not yet committed
+	// to disk) alongside whatever the on-disk scan above found.
+	for _, id := range s.overlay.Children(nil) {
+		if visitedObjects.has(id) || s.overlay.Removed([]string{id}) {
+			continue
+		}
+		object := resolv.EntityRef{ID: id, Type: ocfl.Object, Parent: s.root}
+		if s.contains(object) {
+			if err := cb(object); err != nil {
+				return err
+			}
+		}
+		if s.desired.Type <= ocfl.Version {
+			if err := s.walkOverlayObject(&object, cb); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// visitedSet is a tiny concurrency-safe set used to track which object IDs were
+// already found on disk, so overlay-only objects aren't reported twice.
+type visitedSet struct {
+	mu  sync.Mutex
+	ids map[string]bool
+}
+
+func (v *visitedSet) add(id string) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if v.ids == nil {
+		v.ids = map[string]bool{}
+	}
+	v.ids[id] = true
+}
+
+func (v *visitedSet) has(id string) bool {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	return v.ids[id]
+}
+
+// walkOverlayObject walks the versions and files staged in the overlay for an
+// object that has no on-disk manifest of its own yet (e.g. a brand new object
+// whose first version is still uncommitted).
+func (s *scope) walkOverlayObject(object *resolv.EntityRef, f func(resolv.EntityRef) error) error {
+	for _, vID := range s.overlay.Children([]string{object.ID}) {
+		if s.overlay.Removed([]string{object.ID, vID}) {
+			continue
+		}
+		version := resolv.EntityRef{ID: vID, Type: ocfl.Version, Parent: object}
+		if s.contains(version) {
+			if err := f(version); err != nil {
+				return err
+			}
+		}
+		if s.desired.Type > ocfl.File {
+			continue
+		}
+		for _, lpath := range s.overlay.Children([]string{object.ID, vID}) {
+			coords := []string{object.ID, vID, lpath}
+			if s.overlay.Removed(coords) {
+				continue
+			}
+			src, _ := s.overlay.Replacement(coords)
+			fileRef := resolv.EntityRef{ID: lpath, Type: ocfl.File, Parent: &version, Addr: src}
+			if !s.contains(fileRef) {
+				continue
+			}
+			if err := f(fileRef); err != nil {
+				return err
+			}
+		}
 	}
 	return nil
 }
 
-// Walk the OCFL manifest
-func (s *scope) walkObject(path string, f func(resolv.EntityRef) error) (err error) {
+// Walk the OCFL manifest. visited records this object's logical ID (read from
+// its manifest, not the on-disk scan path) so the "overlay-only objects" pass
+// in walk doesn't report it a second time.
+func (s *scope) walkObject(path string, f func(resolv.EntityRef) error, visited *visitedSet) (err error) {
 
 	inv, err := readMetadata(path)
 	if err != nil {
 		return err
 	}
 
+	if s.overlay.Removed([]string{inv.ID}) {
+		return nil // tombstoned: skip entirely, including its versions and files
+	}
+	visited.add(inv.ID)
+
 	object := resolv.EntityRef{
 		ID:     inv.ID,
 		Type:   ocfl.Object,
@@ -179,14 +365,26 @@ func (s *scope) walkVersions(inv *metadata.Inventory, object *resolv.EntityRef,
 		}
 
 		if s.desired.Type <= ocfl.File {
+			seen := map[string]bool{}
 			files, _ := inv.Files(vID)
 			for _, file := range files {
+				coords := []string{object.ID, vID, file.LogicalPath}
+				seen[file.LogicalPath] = true
+
+				if s.overlay.Removed(coords) {
+					continue
+				}
+
+				addr := filepath.Join(object.Addr, file.PhysicalPath)
+				if src, ok := s.overlay.Replacement(coords); ok {
+					addr = src
+				}
 
 				fileRef := resolv.EntityRef{
 					ID:     file.LogicalPath,
 					Type:   ocfl.File,
 					Parent: &version,
-					Addr:   filepath.Join(object.Addr, file.PhysicalPath),
+					Addr:   addr,
 				}
 
 				if !s.contains(fileRef) {
@@ -198,6 +396,26 @@ func (s *scope) walkVersions(inv *metadata.Inventory, object *resolv.EntityRef,
 					return err
 				}
 			}
+
+			// Files staged in the overlay that don't yet have an on-disk counterpart
+			// in this version (e.g. a new file added ahead of Commit).
+			for _, lpath := range s.overlay.Children([]string{object.ID, vID}) {
+				if seen[lpath] {
+					continue
+				}
+				coords := []string{object.ID, vID, lpath}
+				if s.overlay.Removed(coords) {
+					continue
+				}
+				src, _ := s.overlay.Replacement(coords)
+				fileRef := resolv.EntityRef{ID: lpath, Type: ocfl.File, Parent: &version, Addr: src}
+				if !s.contains(fileRef) {
+					continue
+				}
+				if err := f(fileRef); err != nil {
+					return err
+				}
+			}
 		}
 	}
 
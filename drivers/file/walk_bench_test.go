@@ -0,0 +1,75 @@
+package file
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/birkland/ocfl"
+	"github.com/birkland/ocfl/resolv"
+)
+
+// synthesizeRoot lays out a minimal, bare-bones OCFL root containing n objects,
+// each with a single version and a single file, just enough for isRoot/readMetadata
+// to recognize them during a walk.
+func synthesizeRoot(tb testing.TB, n int) string {
+	root, err := ioutil.TempDir("", "ocfl-bench")
+	if err != nil {
+		tb.Fatal(err)
+	}
+
+	if err := ioutil.WriteFile(filepath.Join(root, "0=ocfl_1.0"), nil, 0644); err != nil {
+		tb.Fatal(err)
+	}
+
+	for i := 0; i < n; i++ {
+		obj := filepath.Join(root, fmt.Sprintf("obj-%d", i))
+		v1 := filepath.Join(obj, "v1", "content")
+		if err := os.MkdirAll(v1, 0755); err != nil {
+			tb.Fatal(err)
+		}
+		if err := ioutil.WriteFile(filepath.Join(obj, "0=ocfl_object_1.0"), nil, 0644); err != nil {
+			tb.Fatal(err)
+		}
+		if err := ioutil.WriteFile(filepath.Join(v1, "file.txt"), []byte("content"), 0644); err != nil {
+			tb.Fatal(err)
+		}
+		inv := fmt.Sprintf(`{"id":"obj-%d","head":"v1","versions":{"v1":{"state":{"e3b0c":["file.txt"]}}},"manifest":{"e3b0c":["v1/content/file.txt"]}}`, i)
+		if err := ioutil.WriteFile(filepath.Join(obj, "inventory.json"), []byte(inv), 0644); err != nil {
+			tb.Fatal(err)
+		}
+	}
+
+	return root
+}
+
+func benchmarkWalk(b *testing.B, objects, concurrency int) {
+	root := synthesizeRoot(b, objects)
+	defer os.RemoveAll(root)
+
+	d := &driver{}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		var count int
+		err := d.Walk(resolv.Select{Type: ocfl.Object, Concurrency: concurrency}, func(e resolv.EntityRef) error {
+			count++
+			return nil
+		}, root)
+		if err != nil {
+			b.Fatal(err)
+		}
+		if count != objects {
+			b.Fatalf("expected %d objects, got %d", objects, count)
+		}
+	}
+}
+
+func BenchmarkWalkSerial(b *testing.B)       { benchmarkWalk(b, 5000, 1) }
+func BenchmarkWalkConcurrent4(b *testing.B)  { benchmarkWalk(b, 5000, 4) }
+func BenchmarkWalkConcurrent16(b *testing.B) { benchmarkWalk(b, 5000, 16) }
+func BenchmarkWalkConcurrentGOMAXPROCS(b *testing.B) {
+	benchmarkWalk(b, 5000, 0)
+}
@@ -0,0 +1,52 @@
+package file
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/birkland/ocfl"
+	"github.com/birkland/ocfl/resolv"
+)
+
+// TestWalkObjectReportedOnceUnderHashedLayout is a regression test for a bug
+// where an object's tombstone/dedup bookkeeping was keyed by its on-disk scan
+// path instead of its logical ID: under a pairtree-style layout (a standard,
+// spec-legal OCFL storage layout) where the object's directory name doesn't
+// match its logical ID, an object with an overlay entry was reported twice -
+// once correctly during the on-disk scan, once more as a bogus overlay-only
+// object.
+func TestWalkObjectReportedOnceUnderHashedLayout(t *testing.T) {
+	root := synthesizeRoot(t, 1) // creates root/obj-0 with inventory id "obj-0"
+
+	// Rename the on-disk dir so its path no longer equals the logical ID,
+	// mimicking a pairtree/hashed storage layout.
+	hashed := filepath.Join(root, "ab", "cd")
+	if err := os.MkdirAll(filepath.Dir(hashed), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Rename(filepath.Join(root, "obj-0"), hashed); err != nil {
+		t.Fatal(err)
+	}
+
+	overlay := resolv.NewOverlay()
+	// Stage a new file into the *existing* object, addressed by its logical ID.
+	overlay.AddFile([]string{"obj-0", "v1", "new.txt"}, "/dev/null")
+
+	d := &driver{overlay: overlay}
+
+	var objectHits int
+	err := d.Walk(resolv.Select{Type: ocfl.Object}, func(e resolv.EntityRef) error {
+		if e.Type == ocfl.Object {
+			objectHits++
+		}
+		return nil
+	}, root)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if objectHits != 1 {
+		t.Fatalf("expected object %q to be reported once, got %d", "obj-0", objectHits)
+	}
+}
@@ -0,0 +1,85 @@
+package resolv
+
+import "fmt"
+
+// Permission is a bitmask of actions a Principal may be granted on an OCFL entity.
+type Permission uint
+
+// The permissions a Principal may be granted on an entity. A Session checks
+// these per operation: Read is required for Session.Read, Write for
+// Session.Put and Session.Commit, and Delete for Session.Delete. Session.Move
+// requires both Write and Delete, since it adds content at one logical path
+// while removing it from another.
+const (
+	Stat Permission = 1 << iota
+	List
+	Read
+	Write
+	Delete
+	AddGrant
+	RemoveGrant
+)
+
+var permissionNames = map[Permission]string{
+	Stat:        "Stat",
+	List:        "List",
+	Read:        "Read",
+	Write:       "Write",
+	Delete:      "Delete",
+	AddGrant:    "AddGrant",
+	RemoveGrant: "RemoveGrant",
+}
+
+func (p Permission) String() string {
+	if name, ok := permissionNames[p]; ok {
+		return name
+	}
+
+	var names []string
+	for bit, name := range permissionNames {
+		if p&bit != 0 {
+			names = append(names, name)
+		}
+	}
+	if len(names) == 0 {
+		return "none"
+	}
+	s := names[0]
+	for _, n := range names[1:] {
+		s += "|" + n
+	}
+	return s
+}
+
+// Principal identifies who is asking to perform an action against an OCFL root.
+// It's deliberately the same shape as Options.User, which is where a caller
+// provides it.
+type Principal struct {
+	Name    string
+	Address string
+}
+
+// Grant records that Principal holds Permission on some entity.
+type Grant struct {
+	Principal  Principal  `json:"principal"`
+	Permission Permission `json:"permission"`
+}
+
+// Authorizer decides whether a Principal has a Permission on a given entity.
+// Drivers consult an Authorizer (if one is configured) on every Walk callback
+// and Session operation.
+type Authorizer interface {
+	HasPermission(user Principal, ref EntityRef, need Permission) (bool, error)
+}
+
+// PermissionDenied is returned by Session operations when Principal lacks the
+// Permission required for the attempted operation.
+type PermissionDenied struct {
+	User Principal
+	Ref  EntityRef
+	Need Permission
+}
+
+func (e *PermissionDenied) Error() string {
+	return fmt.Sprintf("%s: permission denied: needs %s on %s", e.User.Name, e.Need, e.Ref.ID)
+}
@@ -0,0 +1,120 @@
+package resolv
+
+import (
+	"strings"
+	"sync"
+)
+
+// Overlay lets a caller present a set of staged additions, replacements, and
+// removals as if they were already merged with the on-disk OCFL content during a
+// Walk, without writing anything to the underlying storage. It is modeled after
+// the overlay used by cmd/go/internal/fsys: a logical OCFL coordinate (the same
+// {objectID, versionID, logicalPath} triple returned by EntityRef.Coords) maps to
+// either a replacement content location, or a tombstone marking the coordinate (and
+// anything logically beneath it) as removed.
+//
+// An Overlay takes effect only in a driver constructed with it (e.g. the file
+// driver's NewDriver); Init does not pass Config.Overlay on to any Driver. The file
+// driver's Session does not write into an Overlay: it stages its changes under the
+// object's own staging directory until Commit.
+type Overlay struct {
+	mu      sync.RWMutex
+	entries map[string]overlayEntry
+}
+
+type overlayEntry struct {
+	src       string // Replacement content location; meaningless for a tombstone.
+	tombstone bool   // True if the coordinate has been staged for removal.
+}
+
+// NewOverlay returns an empty Overlay.
+func NewOverlay() *Overlay {
+	return &Overlay{entries: make(map[string]overlayEntry)}
+}
+
+// AddFile stages srcPath as the content found at coords, replacing anything
+// on disk, or previously staged, at that coordinate. Any tombstone previously
+// recorded at coords is cleared.
+func (o *Overlay) AddFile(coords []string, srcPath string) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.entries[overlayKey(coords)] = overlayEntry{src: srcPath}
+}
+
+// Remove stages a tombstone at coords, so a Walk skips it (and, for an object or
+// version, everything logically beneath it) even though it may still be present
+// in the underlying storage.
+func (o *Overlay) Remove(coords []string) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.entries[overlayKey(coords)] = overlayEntry{tombstone: true}
+}
+
+// Replacement returns the staged replacement content location for coords, if any.
+func (o *Overlay) Replacement(coords []string) (src string, ok bool) {
+	if o == nil {
+		return "", false
+	}
+	o.mu.RLock()
+	defer o.mu.RUnlock()
+	e, ok := o.entries[overlayKey(coords)]
+	if !ok || e.tombstone {
+		return "", false
+	}
+	return e.src, true
+}
+
+// Removed reports whether coords, or an ancestor of coords, has been tombstoned.
+func (o *Overlay) Removed(coords []string) bool {
+	if o == nil {
+		return false
+	}
+	o.mu.RLock()
+	defer o.mu.RUnlock()
+	for i := len(coords); i > 0; i-- {
+		if e, ok := o.entries[overlayKey(coords[:i])]; ok && e.tombstone {
+			return true
+		}
+	}
+	return false
+}
+
+// Children returns the next coordinate segment of every overlay entry staged
+// immediately beneath parent, so that a Walk can surface objects, versions, or
+// files that exist only in the overlay and have no on-disk counterpart.
+func (o *Overlay) Children(parent []string) []string {
+	if o == nil {
+		return nil
+	}
+	o.mu.RLock()
+	defer o.mu.RUnlock()
+
+	prefix := overlayKey(parent)
+	seen := map[string]bool{}
+	var children []string
+	for key, e := range o.entries {
+		if e.tombstone || key == prefix {
+			continue
+		}
+		rest := key
+		if prefix != "" {
+			if !strings.HasPrefix(key, prefix+"\x00") {
+				continue
+			}
+			rest = key[len(prefix)+1:]
+		}
+		child := rest
+		if i := strings.IndexByte(rest, 0); i >= 0 {
+			child = rest[:i]
+		}
+		if !seen[child] {
+			seen[child] = true
+			children = append(children, child)
+		}
+	}
+	return children
+}
+
+func overlayKey(coords []string) string {
+	return strings.Join(coords, "\x00")
+}
@@ -0,0 +1,44 @@
+package resolv
+
+import (
+	"fmt"
+	"net/url"
+	"sync"
+)
+
+// DriverFactory constructs a Driver rooted at the given address, which may be an
+// absolute filesystem path or a URI such as "s3://bucket/prefix".
+type DriverFactory func(root string) (Driver, error)
+
+var (
+	schemesMu sync.RWMutex
+	schemes   = map[string]DriverFactory{}
+)
+
+// RegisterScheme registers a DriverFactory for the given URI scheme (e.g. "s3"),
+// so that Init can select an appropriate Driver from Config.Root alone when
+// Config.Drivers is empty. Driver packages are expected to call this from an
+// init() function. Registering the same scheme twice replaces the prior factory.
+func RegisterScheme(scheme string, factory DriverFactory) {
+	schemesMu.Lock()
+	defer schemesMu.Unlock()
+	schemes[scheme] = factory
+}
+
+// driverForScheme looks up and constructs the Driver registered for root's URI
+// scheme. A root with no scheme (e.g. a plain filesystem path) has no entry here.
+func driverForScheme(root string) (Driver, error) {
+	u, err := url.Parse(root)
+	if err != nil || u.Scheme == "" {
+		return nil, fmt.Errorf("no driver registered for root %q", root)
+	}
+
+	schemesMu.RLock()
+	factory, ok := schemes[u.Scheme]
+	schemesMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("no driver registered for scheme %q", u.Scheme)
+	}
+
+	return factory(root)
+}
@@ -28,23 +28,25 @@ func (e EntityRef) Coords() []string {
 
 // Options for establishing a read/write session on an OCFL object.
 type Options struct {
-	Create           bool     // If true, this will create a new object if one does not exist.
-	DigestAlgorithms []string // Desired fixity digest algorithms when writing new files.
-	User             struct {
-		Name    string
-		Address string
-	}
+	Create           bool      // If true, this will create a new object if one does not exist.
+	DigestAlgorithms []string  // Desired fixity digest algorithms when writing new files.
+	User             Principal // Principal the session's Authorizer checks are performed as.
 }
 
 // Session allows reading or writing to the an OCFL object. Each session is bound to a single
 // OCFL object version - either a pre-existing version, or an uncommitted new version.
+//
+// OCFL versions are immutable once committed, so Put, Delete, and Move only ever stage
+// intent; nothing is visible outside the session, and no existing version is touched,
+// until Commit atomically materializes the staged changes as a new version. Close
+// ends the session, discarding anything staged but not yet committed.
 type Session interface {
-	Put(lpath string, r io.Reader) error // Put file content at the given logical path
-	// TODO: Delete(lpath string) error
-	// TODO: Move(src, dest string) error
-	// TODO: Read(lpath string) (io.Reader, error)
-	// TODO: Commit() error
-	// TODO: Close() error
+	Put(lpath string, r io.Reader) error  // Put file content at the given logical path
+	Delete(lpath string) error            // Delete removes the file at the given logical path
+	Move(src, dest string) error          // Move relocates a file from src to dest
+	Read(lpath string) (io.Reader, error) // Read returns the content at the given logical path
+	Commit() error                        // Commit atomically materializes staged changes as a new version
+	Close() error                         // Close ends the session, discarding anything not committed
 }
 
 // Opener opens an OCFL object session, potentially allowing reading and writing to it.
@@ -68,8 +70,9 @@ type Walker interface {
 
 // Select indicates desired properties of matching OCFL entities
 type Select struct {
-	Type ocfl.Type // Desired OCFL type
-	Head bool      // True if desired files or versions must be in the head revision
+	Type        ocfl.Type // Desired OCFL type
+	Head        bool      // True if desired files or versions must be in the head revision
+	Concurrency int       // Desired number of goroutines fanned out per-object during a Walk.  0 means GOMAXPROCS.
 }
 
 // Driver provides basic OCFL access via some backend
@@ -79,8 +82,10 @@ type Driver interface {
 }
 
 type Config struct {
-	Root    string
-	Drivers []Driver
+	Root       string
+	Drivers    []Driver
+	Overlay    *Overlay   // Staged changes to present as merged with on-disk content. Optional. Init does not pass it to any Driver.
+	Authorizer Authorizer // Grants to check entities against. Optional. Init does not pass it to any Driver.
 }
 
 // Cxt establishes a context for resolving OCFL entities,
@@ -95,17 +100,30 @@ func Init(cfg Config) (*Cxt, error) {
 	cxt := &Cxt{
 		config: cfg,
 	}
-	if cfg.Root != "" {
-		for _, d := range cfg.Drivers {
-			err := d.Walk(Select{Type: ocfl.Root}, func(r EntityRef) error {
-				cxt.root = &r
-				return nil
-			}, cfg.Root)
-			if err != nil {
-				continue
-			}
-			return cxt, nil
+	if cfg.Root == "" {
+		return nil, fmt.Errorf("No suitable driver found")
+	}
+
+	drivers := cfg.Drivers
+	if len(drivers) == 0 {
+		// No driver was supplied explicitly; fall back to whatever driver
+		// is registered for cfg.Root's URI scheme (e.g. "s3://...").
+		d, err := driverForScheme(cfg.Root)
+		if err != nil {
+			return nil, err
+		}
+		drivers = []Driver{d}
+	}
+
+	for _, d := range drivers {
+		err := d.Walk(Select{Type: ocfl.Root}, func(r EntityRef) error {
+			cxt.root = &r
+			return nil
+		}, cfg.Root)
+		if err != nil {
+			continue
 		}
+		return cxt, nil
 	}
 	return nil, fmt.Errorf("No suitable driver found")
 }